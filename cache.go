@@ -0,0 +1,168 @@
+package gobfuscate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// cacheFormatVersion is folded into every cache key, so a gobfuscate
+// build whose cache layout or pass semantics changed never reuses an
+// entry a different version wrote.
+const cacheFormatVersion = "1"
+
+// cacheMappingDir is the subdirectory (a sibling of the entry
+// directories themselves, not nested inside any of them) where a
+// mapping sidecar is stored per key, recording the symbol renames
+// ObfuscateSymbols produced for that package - so a cache hit can
+// replay them into the live *Mapping without rerunning the pass. It
+// must live outside the entry directory: that directory's contents are
+// copied verbatim into the staged build tree on every hit, and the
+// mapping is exactly what --emit-mapping exists to gate - a warm
+// rebuild must not leak it into the output regardless of that flag.
+const cacheMappingDir = "mappings"
+
+// Cache is a content-addressed, on-disk store of already-obfuscated
+// package source trees, keyed by a hash of that package's own source
+// files together with the seed and which passes produced the entry -
+// mirroring the cache.ActionID approach cmd/go/internal/work uses for
+// the Go build cache itself. ObfuscateGopath consults it before
+// copying and running ObfuscateLiterals/ObfuscateSymbols on a package,
+// and populates it afterward.
+//
+// ObfuscatePackageNames and ObfuscateControlFlow aren't covered by
+// this cache: renaming needs every package's import path available at
+// once to rewrite cross-package imports consistently, and flattening
+// is already a cheap, mechanical per-function rewrite next to the
+// AST-heavy literal/symbol obfuscation this cache targets.
+type Cache struct {
+	dir     string
+	enabled bool
+}
+
+// NewCache returns a Cache rooted at gocache/gobfuscate. enabled false
+// makes every lookup miss and every store a no-op, which is all
+// --cache=off needs.
+func NewCache(gocache string, enabled bool) *Cache {
+	return &Cache{dir: filepath.Join(gocache, "gobfuscate"), enabled: enabled}
+}
+
+// GoCacheDir shells out to `go env GOCACHE`, so gobfuscate's cache
+// lives alongside the Go toolchain's own build cache by default.
+func GoCacheDir() (string, error) {
+	out, err := exec.Command("go", "env", "GOCACHE").Output()
+	if err != nil {
+		return "", fmt.Errorf("go env GOCACHE: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// CleanCache removes every entry gobfuscate has ever cached under
+// gocache.
+func CleanCache(gocache string) error {
+	return os.RemoveAll(filepath.Join(gocache, "gobfuscate"))
+}
+
+// passSet describes which passes (and pass modes) produced a cache
+// entry, so changing --literals or --emit-mapping can never return a
+// stale hit meant for a different configuration.
+func passSet(litMode LiteralsMode, recordMapping bool) string {
+	return fmt.Sprintf("literals=%s,symbols=1,mapping=%v", litMode, recordMapping)
+}
+
+// packageKey hashes a package's own source files together with
+// hasher's seed and passSet. It deliberately only depends on that
+// package's own inputs, not on anything else being built alongside
+// it, so a change to an unrelated package can never invalidate this
+// one.
+func packageKey(files []string, hasher NameHasher, passSet string) (string, error) {
+	names := append([]string{}, files...)
+	sort.Strings(names)
+	h := sha256.New()
+	io.WriteString(h, cacheFormatVersion+"\x00"+hasher.String()+"\x00"+passSet)
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return "", err
+		}
+		io.WriteString(h, "\x00"+filepath.Base(name))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// lookup returns the cached, already-obfuscated tree for key, if one
+// exists.
+func (c *Cache) lookup(key string) (string, bool) {
+	if !c.enabled {
+		return "", false
+	}
+	entry := filepath.Join(c.dir, key)
+	info, err := os.Stat(entry)
+	if err != nil || !info.IsDir() {
+		return "", false
+	}
+	return entry, true
+}
+
+// store saves srcDir's current contents as the cache entry for key.
+func (c *Cache) store(key, srcDir string) error {
+	if !c.enabled {
+		return nil
+	}
+	entry := filepath.Join(c.dir, key)
+	if err := os.RemoveAll(entry); err != nil {
+		return err
+	}
+	return copyTree(srcDir, entry)
+}
+
+// storeMapping saves local as key's mapping sidecar, if caching is on.
+func (c *Cache) storeMapping(key string, local *Mapping) error {
+	if !c.enabled {
+		return nil
+	}
+	path := filepath.Join(c.dir, cacheMappingDir, key+".json")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return local.WriteFile(path)
+}
+
+// mapping loads key's mapping sidecar, if one was stored.
+func (c *Cache) mapping(key string) *Mapping {
+	data, err := os.ReadFile(filepath.Join(c.dir, cacheMappingDir, key+".json"))
+	if err != nil {
+		return nil
+	}
+	m := NewMapping()
+	if json.Unmarshal(data, m) != nil {
+		return nil
+	}
+	return m
+}
+
+// copyTree copies every file under srcDir into destDir, preserving its
+// relative layout.
+func copyTree(srcDir, destDir string) error {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		return copyFile(path, filepath.Join(destDir, rel))
+	})
+}
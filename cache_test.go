@@ -0,0 +1,56 @@
+package gobfuscate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCacheMappingNotLeakedIntoEntry reproduces the reported leak: the
+// mapping sidecar used to live inside the cache entry directory, so a
+// cache hit's copyTree (which stages the entry's whole contents into
+// the real build tree) shipped the original -> hashed name map
+// regardless of --emit-mapping. The sidecar must live outside the
+// entry directory.
+func TestCacheMappingNotLeakedIntoEntry(t *testing.T) {
+	gocache := t.TempDir()
+	cache := NewCache(gocache, true)
+
+	srcDir := t.TempDir()
+	writeFile(t, filepath.Join(srcDir, "a.go"), "package pkg\n")
+
+	mapping := NewMapping()
+	mapping.addFunc("helper", "O1234567890abcdef")
+
+	const key = "deadbeef"
+	if err := cache.store(key, srcDir); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if err := cache.storeMapping(key, mapping); err != nil {
+		t.Fatalf("storeMapping: %v", err)
+	}
+
+	destDir := t.TempDir()
+	entry, ok := cache.lookup(key)
+	if !ok {
+		t.Fatal("expected cache hit")
+	}
+	if err := copyTree(entry, destDir); err != nil {
+		t.Fatalf("copyTree: %v", err)
+	}
+
+	entries, err := os.ReadDir(destDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "a.go" {
+			t.Fatalf("unexpected file staged into build tree: %s", e.Name())
+		}
+	}
+
+	restored := cache.mapping(key)
+	if restored == nil || restored.Funcs["helper"] != "O1234567890abcdef" {
+		t.Fatalf("mapping sidecar not restored: %+v", restored)
+	}
+}
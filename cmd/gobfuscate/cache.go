@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sonnt85/gobfuscate"
+	"github.com/spf13/cobra"
+)
+
+// CacheCmd groups cache-maintenance subcommands under `gobfuscate cache`.
+var CacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect or manage the per-package obfuscation cache used by --cache",
+}
+
+var cacheCleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove every entry gobfuscate has cached under $GOCACHE/gobfuscate",
+	Run: func(cmd *cobra.Command, args []string) {
+		gocache, err := gobfuscate.GoCacheDir()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to resolve GOCACHE:", err)
+			os.Exit(1)
+		}
+		if err := gobfuscate.CleanCache(gocache); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to clean cache:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	CacheCmd.AddCommand(cacheCleanCmd)
+}
@@ -1,13 +1,13 @@
 package cmd
 
 import (
-	"crypto/rand"
 	"flag"
 	"fmt"
 	"go/build"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
 	"github.com/sonnt85/gobfuscate"
@@ -18,6 +18,9 @@ import (
 // Command line arguments.
 var (
 	customPadding       string
+	seed                string
+	literalsMode        string
+	controlflowMode     string
 	tags                string
 	outputGopath        bool
 	keepTests           bool
@@ -28,6 +31,9 @@ var (
 	preservePackageName bool
 	verbose             bool
 	ignoreDelTmp        bool
+	debugDir            string
+	emitMapping         bool
+	cacheMode           string
 )
 
 var (
@@ -47,6 +53,12 @@ var (
 
 			pkgName := args[0]
 			outPath := args[1]
+			if toolexecMode {
+				if !wrapBuild(pkgName, outPath) {
+					os.Exit(1)
+				}
+				return
+			}
 			// os.Setenv("GO111MODULE", "off")
 			if !obfuscate(pkgName, outPath) {
 				os.Exit(1)
@@ -61,7 +73,10 @@ func Execute() {
 }
 
 func Init() {
-	BuildCmd.Flags().StringVarP(&customPadding, "padding", "p", "", "use a custom padding for hashing sensitive information (otherwise a random padding will be used)")
+	BuildCmd.Flags().StringVarP(&customPadding, "padding", "p", "", "deprecated, use --seed instead; raw (non-base64) padding for hashing sensitive information")
+	BuildCmd.Flags().StringVar(&seed, "seed", "random", `"random" or a base64-encoded seed; a fixed seed makes the build reproducible byte-for-byte`)
+	BuildCmd.Flags().StringVar(&literalsMode, "literals", "strings", "which literal kinds to obfuscate: all|strings|numeric|off")
+	BuildCmd.Flags().StringVar(&controlflowMode, "controlflow", "off", "control-flow flattening aggressiveness: off|low|high")
 	BuildCmd.Flags().BoolVarP(&outputGopath, "nobuild", "n", false, "only copy source code, GOPATH to new dir then exit, need manual build")
 	BuildCmd.Flags().BoolVarP(&keepTests, "keeptests", "k", false, "keep _test.go files")
 	BuildCmd.Flags().BoolVarP(&winHide, "winhide", "w", false, "hide windows GUI")
@@ -73,9 +88,28 @@ func Init() {
 	BuildCmd.Flags().StringVarP(&tags, "tags", "t", "", "tags are passed to the go compiler")
 	BuildCmd.Flags().StringVar(&ldf, "ldf", "", "more ldflag when build")
 	BuildCmd.Flags().StringVar(&go11module, "go11module", "auto", "env go11module")
+	BuildCmd.Flags().BoolVar(&toolexecMode, "toolexec", false,
+		"obfuscate in place via 'go build -toolexec' instead of copying into a scratch GOPATH (required for module-based projects)")
+	BuildCmd.Flags().StringVar(&debugDir, "debugdir", "",
+		"write the obfuscated source tree here for inspection before building (not supported with --toolexec)")
+	BuildCmd.Flags().BoolVar(&emitMapping, "emit-mapping", false,
+		"with --debugdir, also write mapping.json recording original -> hashed names; never ship this alongside a release build")
+	BuildCmd.Flags().StringVar(&cacheMode, "cache", "on",
+		"per-package obfuscation build cache under $GOCACHE/gobfuscate: on|off")
+	BuildCmd.AddCommand(ToolexecCmd)
+	BuildCmd.AddCommand(CacheCmd)
 }
 
 func obfuscate(pkgName, outPath string) bool {
+	n, err := resolveHasher()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to resolve seed:", err)
+		return false
+	}
+	if verbose {
+		fmt.Fprintln(os.Stderr, "[Verbose] Seed:", n.String())
+	}
+
 	var newGopath string
 	if outputGopath {
 		newGopath = outPath
@@ -84,9 +118,15 @@ func obfuscate(pkgName, outPath string) bool {
 			return false
 		}
 	} else {
-		var err error
-		newGopath, err = os.MkdirTemp("", "")
-		if err != nil {
+		// Derived from the seed rather than os.MkdirTemp, so that a
+		// fixed --seed also pins the staging path baked into the
+		// binary by -trimpath, making the build byte-reproducible.
+		newGopath = filepath.Join(os.TempDir(), "gobfuscate-"+n.Hash("gopath"))
+		if err := os.RemoveAll(newGopath); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to clear staging dir:", err)
+			return false
+		}
+		if err := os.MkdirAll(newGopath, 0755); err != nil {
 			fmt.Fprintln(os.Stderr, "Failed to create temp dir:", err)
 			return false
 		}
@@ -99,39 +139,45 @@ func obfuscate(pkgName, outPath string) bool {
 	}
 	log.Printf("Origin GOPATH: %s\nGO111MODULE: %s", os.Getenv("GOPATH"), os.Getenv("GO111MODULE"))
 
-	log.Printf("Copying to new GOPATH %s...\n", newGopath)
+	gocache, err := gobfuscate.GoCacheDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to resolve GOCACHE:", err)
+		return false
+	}
+	cache := gobfuscate.NewCache(gocache, cacheMode != "off")
+
+	var mapping *gobfuscate.Mapping
+	if emitMapping {
+		mapping = gobfuscate.NewMapping()
+	}
 
-	if err := gobfuscate.CopyGopath(pkgName, newGopath, keepTests); err != nil {
+	log.Printf("Copying and obfuscating packages into new GOPATH %s...\n", newGopath)
+	if err := gobfuscate.ObfuscateGopath(pkgName, newGopath, n, gobfuscate.LiteralsMode(literalsMode), keepTests, mapping, cache); err != nil {
 		moreInfo := "\nNote: Setting GO111MODULE env variable to `off` may resolve the above error."
 		if os.Getenv("GO111MODULE") == "off" {
 			moreInfo = ""
 		}
-		fmt.Fprintln(os.Stderr, "Failed to copy into a new GOPATH:", err, moreInfo)
+		fmt.Fprintln(os.Stderr, "Failed to copy and obfuscate into a new GOPATH:", err, moreInfo)
 		return false
 	}
-	var n gobfuscate.NameHasher
-	if customPadding == "" {
-		buf := make([]byte, 32)
-		rand.Read(buf)
-		n = buf
-	} else {
-		n = []byte(customPadding)
-	}
 
 	log.Println("Obfuscating package names...")
-	if err := gobfuscate.ObfuscatePackageNames(newGopath, n); err != nil {
+	if err := gobfuscate.ObfuscatePackageNames(newGopath, n, mapping); err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to obfuscate package names:", err)
 		return false
 	}
-	log.Println("Obfuscating strings...")
-	if err := gobfuscate.ObfuscateStrings(newGopath); err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to obfuscate strings:", err)
+	log.Println("Flattening control flow...")
+	if err := gobfuscate.ObfuscateControlFlow(newGopath, n, gobfuscate.ControlFlowMode(controlflowMode)); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to flatten control flow:", err)
 		return false
 	}
-	log.Println("Obfuscating symbols...")
-	if err := gobfuscate.ObfuscateSymbols(newGopath, n); err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to obfuscate symbols:", err)
-		return false
+
+	if debugDir != "" {
+		log.Printf("Writing debug dir %s...\n", debugDir)
+		if err := gobfuscate.WriteDebugDir(newGopath, debugDir, mapping); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to write debug dir:", err)
+			return false
+		}
 	}
 
 	if outputGopath {
@@ -194,6 +240,86 @@ func obfuscate(pkgName, outPath string) bool {
 	return true
 }
 
+// wrapBuild obfuscates pkgName without ever copying it out of the
+// user's module tree: it runs `go build -toolexec` with this binary
+// re-invoked as the wrapper, so gobfuscate sees (and rewrites) one
+// package at a time as the real toolchain asks to compile it. This is
+// the only mode that works for module-based projects, since GOPATH mode
+// builds its staged copy with GO111MODULE=off.
+func wrapBuild(pkgName, outPath string) bool {
+	n, err := resolveHasher()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to resolve seed:", err)
+		return false
+	}
+	if verbose {
+		fmt.Fprintln(os.Stderr, "[Verbose] Seed:", n.String())
+	}
+	if debugDir != "" {
+		fmt.Fprintln(os.Stderr, "Warning: --debugdir is not supported with --toolexec; ignoring")
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to resolve gobfuscate executable:", err)
+		return false
+	}
+
+	ldflags := `-s -w`
+	if winHide {
+		ldflags += " -H=windowsgui"
+	}
+	if !noStaticLink {
+		ldflags += ` -extldflags '-static'`
+	}
+	if len(ldf) != 0 {
+		ldflags += " " + ldf
+	}
+
+	arguments := []string{
+		"build",
+		"-toolexec", self + " toolexec --",
+		"-trimpath",
+		"-ldflags", ldflags,
+		"-tags", tags,
+		"-o", outPath,
+		pkgName,
+	}
+
+	cmd := exec.Command("go", arguments...)
+	// Every -toolexec invocation re-execs "gobfuscate toolexec" as a
+	// fresh process, so the seed has to cross that boundary through the
+	// environment rather than the in-memory hasher resolved above.
+	cmd.Env = append(os.Environ(), toolexecSeedEnv+"="+n.String())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if verbose {
+		fmt.Println()
+		fmt.Println("[Verbose] Wrap mode, build command: go", strings.Join(arguments, " "))
+		fmt.Println()
+	}
+
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to compile:", err)
+		return false
+	}
+	return true
+}
+
+// resolveHasher turns the --seed (or legacy --padding) flags into the
+// NameHasher every obfuscation pass seeds its randomness from.
+// --padding wins if set, for backwards compatibility with existing
+// invocations; otherwise --seed is parsed, with "random" drawing fresh
+// crypto/rand entropy and anything else treated as a pinned,
+// base64-encoded seed that reproduces an identical build.
+func resolveHasher() (gobfuscate.NameHasher, error) {
+	if customPadding != "" {
+		return gobfuscate.NameHasher(customPadding), nil
+	}
+	return gobfuscate.NewSeed(seed)
+}
+
 func encryptComponents(pkgName string, n gobfuscate.NameHasher) string {
 	comps := strings.Split(pkgName, "/")
 	for i, comp := range comps {
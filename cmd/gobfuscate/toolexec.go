@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/sonnt85/gobfuscate"
+	"github.com/spf13/cobra"
+)
+
+// Command line arguments specific to wrap/toolexec mode.
+var (
+	toolexecMode bool
+)
+
+// ToolexecCmd is never invoked directly by users. BuildCmd passes
+// "gobfuscate toolexec --" to `go build -toolexec` when --toolexec is
+// set, and the Go toolchain then re-execs this command once per
+// compile/link/asm step, with the real tool and its original
+// arguments appended after "--". That lets gobfuscate obfuscate each
+// package's sources right before they are compiled, without ever
+// copying the user's module tree into a scratch GOPATH.
+var ToolexecCmd = &cobra.Command{
+	Use:                "toolexec -- tool [args...]",
+	Short:              "Internal -toolexec entry point used by build --toolexec",
+	Hidden:             true,
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// DisableFlagParsing hands over the "--" separator as well.
+		if len(args) > 0 && args[0] == "--" {
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			fmt.Fprintln(os.Stderr, "toolexec: missing wrapped tool invocation")
+			os.Exit(1)
+		}
+		hasher, err := hasherFromEnv()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "toolexec:", err)
+			os.Exit(1)
+		}
+		if err := runToolexec(args, hasher); err != nil {
+			fmt.Fprintln(os.Stderr, "toolexec:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// toolexecSeedEnv carries the seed from wrapBuild down to every
+// `gobfuscate toolexec` process `go build -toolexec` re-execs: each is
+// a fresh process, so a package-level var set once in the parent (as
+// wrapBuild used to do) is never seen by them, and the --seed/--padding
+// the user asked for would silently be ignored in wrap mode.
+const toolexecSeedEnv = "GOBFUSCATE_TOOLEXEC_SEED"
+
+// hasherFromEnv reconstructs the NameHasher wrapBuild resolved from
+// --seed/--padding, via the env var it set on the `go build` it
+// launched.
+func hasherFromEnv() (gobfuscate.NameHasher, error) {
+	seed := os.Getenv(toolexecSeedEnv)
+	if seed == "" {
+		return nil, fmt.Errorf("%s not set (toolexec must be invoked by build --toolexec)", toolexecSeedEnv)
+	}
+	return gobfuscate.NewSeed(seed)
+}
+
+// runToolexec obfuscates the package being compiled (if any) into its
+// stage directory, rewrites the tool's source-file arguments to point
+// there, then execs the real tool in place of this process.
+func runToolexec(args []string, hasher gobfuscate.NameHasher) error {
+	tool, toolArgs := args[0], args[1:]
+
+	if filepath.Base(tool) == "compile" || filepath.Base(tool) == toolExeSuffix("compile") {
+		staged, err := stageAndObfuscatePackage(toolArgs, hasher)
+		if err != nil {
+			return fmt.Errorf("stage package: %w", err)
+		}
+		toolArgs = staged
+	}
+
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(path, append([]string{path}, toolArgs...), os.Environ())
+}
+
+// stageAndObfuscatePackage copies the .go sources named in a `compile`
+// invocation into a scratch GOPATH containing only this package, runs
+// ObfuscateLiterals and ObfuscateSymbols on it, and returns the tool
+// arguments with source paths rewritten to the staged copies. Package
+// names and control flow are not obfuscated in this mode.
+//
+// go build runs compile steps for different packages concurrently, and
+// ObfuscateLiterals/ObfuscateSymbols each walk an entire gopath/src
+// tree - so every invocation gets its own fresh scratch GOPATH
+// (mirroring pipeline.go's obfuscatePackage) rather than sharing one
+// under GOCACHE, which would let one package's pass read and rewrite
+// another's staged files mid-compile.
+func stageAndObfuscatePackage(toolArgs []string, hasher gobfuscate.NameHasher) ([]string, error) {
+	importPath := flagValue(toolArgs, "-p")
+	if importPath == "" {
+		return toolArgs, nil
+	}
+
+	gocache := os.Getenv("GOCACHE")
+	if gocache == "" {
+		gocache = os.TempDir()
+	}
+	fakeGopath, err := os.MkdirTemp(gocache, "gobfuscate-toolexec-")
+	if err != nil {
+		return nil, err
+	}
+	fakeSrc := filepath.Join(fakeGopath, "src", importPath)
+	if err := os.MkdirAll(fakeSrc, 0755); err != nil {
+		return nil, err
+	}
+
+	rewritten := make([]string, len(toolArgs))
+	copy(rewritten, toolArgs)
+	for i, arg := range rewritten {
+		if !strings.HasSuffix(arg, ".go") {
+			continue
+		}
+		dest := filepath.Join(fakeSrc, filepath.Base(arg))
+		if err := copyFile(arg, dest); err != nil {
+			return nil, err
+		}
+		rewritten[i] = dest
+	}
+
+	if err := gobfuscate.ObfuscateLiterals(fakeGopath, hasher, gobfuscate.LiteralsMode(literalsMode)); err != nil {
+		return nil, err
+	}
+	if err := gobfuscate.ObfuscateSymbols(fakeGopath, hasher, nil); err != nil {
+		return nil, err
+	}
+	for i, arg := range rewritten {
+		if strings.HasSuffix(arg, ".go") {
+			rewritten[i] = filepath.Join(fakeSrc, filepath.Base(arg))
+		}
+	}
+	return rewritten, nil
+}
+
+func flagValue(args []string, name string) string {
+	for i, arg := range args {
+		if arg == name && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func toolExeSuffix(name string) string {
+	if strings.HasSuffix(os.Args[0], ".exe") {
+		return name + ".exe"
+	}
+	return name
+}
+
+func copyFile(src, dest string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dest, data, 0644)
+}
@@ -0,0 +1,577 @@
+package gobfuscate
+
+import (
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	mathrand "math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ControlFlowMode selects how aggressively ObfuscateControlFlow
+// rewrites function bodies into dispatcher-loop form.
+type ControlFlowMode string
+
+// Values accepted by the --controlflow flag.
+const (
+	ControlFlowOff  ControlFlowMode = "off"
+	ControlFlowLow  ControlFlowMode = "low"
+	ControlFlowHigh ControlFlowMode = "high"
+)
+
+// cfgOpaqueVar is the package-level var ObfuscateControlFlow adds
+// (once per package, on demand) to back opaque-predicate bogus branches with a
+// value no constant-folding pass can see through.
+const cfgOpaqueVar = "_gobfuscateCFGOpaque"
+
+// ObfuscateControlFlow walks every non-test Go source file under
+// gopath/src and rewrites eligible function bodies into a
+// dispatcher-loop form: "state := s0; dispatch: for { switch state {
+// case s0: ...; case s1: ... } }". Each top-level statement of the
+// original body (or run of statements) becomes one numbered case, and
+// the body's original sequential and branching structure becomes state
+// transitions between cases.
+//
+// Nothing below the top level of a function body is touched: an
+// if/for/switch statement is moved whole into its own case rather than
+// flattened recursively. That keeps most of the rewrite purely
+// syntactic, with one exception - a top-level `x := ...` only declares
+// x within its own case's scope, so a later case reading x needs it
+// hoisted to a `var x T` ahead of the dispatcher loop. Getting T right
+// needs real type information, so this pass type-checks each package
+// with go/types before flattening it; a package that fails to
+// type-check (for whatever reason - a missing transitive dependency,
+// build tags go/types doesn't evaluate the same way the real build
+// would) is left unflattened rather than risk guessing wrong.
+//
+// ControlFlowLow only isolates existing branch points (if/for/switch/
+// return) into their own cases and rarely adds a bogus branch.
+// ControlFlowHigh also splits straight-line runs at random points and
+// adds bogus branches aggressively. ControlFlowOff is a no-op.
+func ObfuscateControlFlow(gopath string, hasher NameHasher, mode ControlFlowMode) error {
+	if mode == ControlFlowOff {
+		return nil
+	}
+
+	// The go/types importer resolves this package's imports (already
+	// rewritten to their hashed paths by the earlier passes) by
+	// searching GOPATH, so it needs to see this gopath, not whatever
+	// the surrounding process was launched with.
+	restoreEnv := setGopathEnv(gopath)
+	defer restoreEnv()
+
+	srcDir := filepath.Join(gopath, "src")
+
+	dirs := map[string][]string{}
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		dir := filepath.Dir(path)
+		dirs[dir] = append(dirs[dir], path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var dirNames []string
+	for dir := range dirs {
+		dirNames = append(dirNames, dir)
+	}
+	sort.Strings(dirNames)
+
+	for _, dir := range dirNames {
+		if err := obfuscateControlFlowInDir(dirs[dir], srcDir, hasher, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setGopathEnv(gopath string) func() {
+	prevGopath, hadGopath := os.LookupEnv("GOPATH")
+	prevModule, hadModule := os.LookupEnv("GO111MODULE")
+	// The "source" importer searches build.Default, whose GOPATH was read
+	// from the environment once at startup, so point it at gopath too.
+	prevDefaultGopath := build.Default.GOPATH
+	build.Default.GOPATH = gopath
+	os.Setenv("GOPATH", gopath)
+	os.Setenv("GO111MODULE", "off")
+	return func() {
+		build.Default.GOPATH = prevDefaultGopath
+		if hadGopath {
+			os.Setenv("GOPATH", prevGopath)
+		} else {
+			os.Unsetenv("GOPATH")
+		}
+		if hadModule {
+			os.Setenv("GO111MODULE", prevModule)
+		} else {
+			os.Unsetenv("GO111MODULE")
+		}
+	}
+}
+
+// obfuscateControlFlowInDir processes every file of a single package
+// together, since both the runtime/reflect exemption and type-checking
+// are whole-package decisions.
+func obfuscateControlFlowInDir(paths []string, srcDir string, hasher NameHasher, mode ControlFlowMode) error {
+	fset := token.NewFileSet()
+	files := make([]*ast.File, len(paths))
+	for i, path := range paths {
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		files[i] = file
+	}
+	if packageTouchesRuntimeOrReflect(files) {
+		return nil
+	}
+
+	info, ok := typeCheckPackage(fset, files)
+	if !ok {
+		return nil
+	}
+
+	var changedFiles []int
+	needsOpaqueVar := false
+	for i, file := range files {
+		rel, err := filepath.Rel(srcDir, paths[i])
+		if err != nil {
+			return err
+		}
+		qf := qualifierForFile(file)
+		changed := false
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			rnd := hasher.Rand(filepath.ToSlash(rel) + "#" + fn.Name.Name)
+			usedOpaque, didFlatten := flattenFunc(fn, rnd, mode, info, qf)
+			if !didFlatten {
+				continue
+			}
+			changed = true
+			needsOpaqueVar = needsOpaqueVar || usedOpaque
+		}
+		if changed {
+			changedFiles = append(changedFiles, i)
+		}
+	}
+	if len(changedFiles) == 0 {
+		return nil
+	}
+
+	if needsOpaqueVar {
+		// cfgOpaqueVar is a package-level var: declaring it in every
+		// changed file would redeclare it as soon as a package has more
+		// than one file using an opaque predicate. Declare it once, in
+		// whichever changed file sorts first.
+		host := files[changedFiles[0]]
+		ensureImport(host, "os")
+		host.Decls = append(host.Decls, &ast.GenDecl{
+			Tok: token.VAR,
+			Specs: []ast.Spec{&ast.ValueSpec{
+				Names: []*ast.Ident{ast.NewIdent(cfgOpaqueVar)},
+				Values: []ast.Expr{&ast.CallExpr{
+					Fun:  ast.NewIdent("len"),
+					Args: []ast.Expr{&ast.SelectorExpr{X: ast.NewIdent("os"), Sel: ast.NewIdent("Args")}},
+				}},
+			}},
+		})
+	}
+
+	for _, i := range changedFiles {
+		out, err := os.Create(paths[i])
+		if err != nil {
+			return err
+		}
+		err = printer.Fprint(out, fset, files[i])
+		out.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// typeCheckPackage type-checks files as a single package, tolerating
+// (by recording, not aborting on) individual errors - the caller treats
+// any error as "don't flatten this package" rather than failing the
+// whole obfuscation run over it. go/types keeps checking past an error
+// where it can, so info is still worth consulting even when ok is
+// false: literal_pass.go does exactly that, since a single unresolved
+// import shouldn't cost it every other expression's type in the file.
+func typeCheckPackage(fset *token.FileSet, files []*ast.File) (*types.Info, bool) {
+	info := &types.Info{
+		Defs:  map[*ast.Ident]types.Object{},
+		Uses:  map[*ast.Ident]types.Object{},
+		Types: map[ast.Expr]types.TypeAndValue{},
+	}
+	ok := true
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error:    func(err error) { ok = false },
+	}
+	conf.Check("", fset, files, info)
+	return info, ok
+}
+
+// qualifierForFile returns a types.Qualifier that prints an imported
+// package's types using whatever local name this file already imports
+// it under, so the generated `var` declarations reference packages the
+// same way the rest of the file does.
+func qualifierForFile(file *ast.File) types.Qualifier {
+	aliases := map[string]string{}
+	for _, imp := range file.Imports {
+		path := strings.Trim(imp.Path.Value, `"`)
+		name := path
+		if idx := strings.LastIndex(path, "/"); idx >= 0 {
+			name = path[idx+1:]
+		}
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		aliases[path] = name
+	}
+	return func(p *types.Package) string {
+		if p == nil || p.Path() == "" {
+			// "" is the path typeCheckPackage gives the package under
+			// test itself (it calls conf.Check("", ...)), so this is a
+			// reference to a type declared in the very file/package
+			// being rewritten - print it unqualified.
+			return ""
+		}
+		if alias, ok := aliases[p.Path()]; ok {
+			return alias
+		}
+		return p.Name()
+	}
+}
+
+// packageTouchesRuntimeOrReflect reports whether any file imports
+// runtime or reflect, in which case the whole package is left alone:
+// flattening can change stack shapes and confuse callers that inspect
+// them (runtime.Caller, reflect-based introspection of call sites).
+func packageTouchesRuntimeOrReflect(files []*ast.File) bool {
+	for _, f := range files {
+		for _, imp := range f.Imports {
+			path := strings.Trim(imp.Path.Value, `"`)
+			if path == "runtime" || path == "reflect" ||
+				strings.HasPrefix(path, "runtime/") || strings.HasPrefix(path, "reflect/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// flattenFunc rewrites fn's body in place. changed reports whether
+// anything was rewritten; usedOpaque reports whether the rewrite needs
+// cfgOpaqueVar declared in the file.
+func flattenFunc(fn *ast.FuncDecl, rnd *mathrand.Rand, mode ControlFlowMode, info *types.Info, qf types.Qualifier) (usedOpaque, changed bool) {
+	if !eligibleForFlattening(fn) {
+		return false, false
+	}
+
+	blocks := splitBlocks(fn.Body.List, mode, rnd)
+	if len(blocks) < 2 {
+		return false, false
+	}
+	prologue, blocks, ok := hoistLocals(blocks, info, qf)
+	if !ok {
+		return false, false
+	}
+
+	// Spread state IDs out over a wider range than len(blocks) so they
+	// don't read as a sequential 0,1,2,... case list.
+	ids := rnd.Perm(len(blocks)*3 + 1)[:len(blocks)]
+
+	stateVar := ast.NewIdent("_gobfuscateState")
+	label := ast.NewIdent("_gobfuscateDispatch")
+	hasResults := fn.Type.Results != nil && len(fn.Type.Results.List) > 0
+
+	cases := make([]ast.Stmt, len(blocks))
+	for i, block := range blocks {
+		body := append([]ast.Stmt{}, block...)
+		if i == len(blocks)-1 {
+			// A function with results must already end, syntactically,
+			// in a terminating statement (Go wouldn't have compiled it
+			// otherwise) - and that statement is still here, copied
+			// verbatim as this last case's body. Adding a break here
+			// would stop this "for {}" from being recognized as an
+			// infinite loop (Go's terminating-statement rule for `for`
+			// requires no break referring to it), which would make the
+			// compiler think the function can fall off the end.
+			// Void functions have no such statement to fall back on, so
+			// they need the break to actually exit the dispatcher loop.
+			if !hasResults {
+				body = append(body, &ast.BranchStmt{Tok: token.BREAK, Label: label})
+			}
+		} else {
+			next := ids[i+1]
+			inject := (mode == ControlFlowHigh && rnd.Intn(4) != 0) || (mode == ControlFlowLow && rnd.Intn(4) == 0)
+			if inject {
+				body = append(body, opaquePredicateStmt(stateVar, next))
+				usedOpaque = true
+			} else {
+				body = append(body, &ast.AssignStmt{Lhs: []ast.Expr{stateVar}, Tok: token.ASSIGN, Rhs: []ast.Expr{cfgIntLit(next)}})
+			}
+		}
+		cases[i] = &ast.CaseClause{List: []ast.Expr{cfgIntLit(ids[i])}, Body: body}
+	}
+
+	dispatchLoop := &ast.ForStmt{
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			&ast.SwitchStmt{Tag: stateVar, Body: &ast.BlockStmt{List: cases}},
+		}},
+	}
+
+	newBody := append([]ast.Stmt{}, prologue...)
+	newBody = append(newBody, &ast.AssignStmt{Lhs: []ast.Expr{stateVar}, Tok: token.DEFINE, Rhs: []ast.Expr{cfgIntLit(ids[0])}})
+	if hasResults {
+		// No break targets the loop in this case (see above), so a label
+		// here would be dead and Go rejects unused labels.
+		newBody = append(newBody, dispatchLoop)
+	} else {
+		newBody = append(newBody, &ast.LabeledStmt{Label: label, Stmt: dispatchLoop})
+	}
+	fn.Body.List = newBody
+	return usedOpaque, true
+}
+
+// eligibleForFlattening skips functions where flattening would be
+// unsafe or pointless: no body, named return values (the dispatcher
+// form doesn't preserve the implicit "return" semantics they rely on),
+// or defer/go/select/goto anywhere in the body. Closures defined in the
+// body aren't descended into - whatever they do internally is their own
+// function's scope, not this one's.
+func eligibleForFlattening(fn *ast.FuncDecl) bool {
+	if fn.Body == nil || len(fn.Body.List) == 0 {
+		return false
+	}
+	if fn.Type.Results != nil {
+		for _, field := range fn.Type.Results.List {
+			if len(field.Names) > 0 {
+				return false
+			}
+		}
+	}
+
+	eligible := true
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch s := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.DeferStmt, *ast.GoStmt, *ast.SelectStmt, *ast.LabeledStmt:
+			eligible = false
+			return false
+		case *ast.BranchStmt:
+			if s.Tok == token.GOTO {
+				eligible = false
+				return false
+			}
+		}
+		return true
+	})
+	return eligible
+}
+
+// splitBlocks breaks stmts into the dispatcher's cases: every
+// if/for/range/switch/return statement becomes its own case, and
+// everything between them stays together as one case. In
+// ControlFlowHigh, straight-line runs are also randomly split further.
+func splitBlocks(stmts []ast.Stmt, mode ControlFlowMode, rnd *mathrand.Rand) [][]ast.Stmt {
+	var blocks [][]ast.Stmt
+	var current []ast.Stmt
+	flush := func() {
+		if len(current) > 0 {
+			blocks = append(blocks, current)
+			current = nil
+		}
+	}
+	for _, stmt := range stmts {
+		if isBranchStmt(stmt) {
+			flush()
+			blocks = append(blocks, []ast.Stmt{stmt})
+			continue
+		}
+		current = append(current, stmt)
+		if mode == ControlFlowHigh && len(current) > 1 && rnd.Intn(3) == 0 {
+			flush()
+		}
+	}
+	flush()
+	return blocks
+}
+
+func isBranchStmt(stmt ast.Stmt) bool {
+	switch stmt.(type) {
+	case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.ReturnStmt:
+		return true
+	}
+	return false
+}
+
+// hoistLocals moves every top-level variable declaration out of its
+// block and into a prologue of `var name T` statements ahead of the
+// dispatcher loop, rewriting the original `:=` (or `var name T = v`)
+// into a plain assignment in place. It reports ok=false if it finds
+// anything it doesn't know how to hoist safely (a grouped or
+// multi-name var statement, a local const or type declaration, or an
+// identifier go/types has no recorded type for), in which case the
+// caller leaves the function unflattened.
+func hoistLocals(blocks [][]ast.Stmt, info *types.Info, qf types.Qualifier) ([]ast.Stmt, [][]ast.Stmt, bool) {
+	var prologue []ast.Stmt
+	hoisted := map[string]bool{}
+	newBlocks := make([][]ast.Stmt, len(blocks))
+
+	for bi, block := range blocks {
+		newBlock := make([]ast.Stmt, 0, len(block))
+		for _, stmt := range block {
+			switch s := stmt.(type) {
+			case *ast.AssignStmt:
+				if s.Tok != token.DEFINE {
+					newBlock = append(newBlock, s)
+					continue
+				}
+				for _, lhs := range s.Lhs {
+					ident, ok := lhs.(*ast.Ident)
+					if !ok || ident.Name == "_" || hoisted[ident.Name] {
+						continue
+					}
+					obj := info.Defs[ident]
+					if obj == nil {
+						// Not a new declaration here (re-used from an
+						// earlier multi-assign with :=); already hoisted.
+						continue
+					}
+					if !usableType(obj.Type()) {
+						return nil, nil, false
+					}
+					prologue = append(prologue, varDecl(ident.Name, obj.Type(), qf))
+					hoisted[ident.Name] = true
+				}
+				s.Tok = token.ASSIGN
+				newBlock = append(newBlock, s)
+			case *ast.DeclStmt:
+				pro, repl, ok := splitDeclStmt(s, info, qf)
+				if !ok {
+					return nil, nil, false
+				}
+				prologue = append(prologue, pro)
+				if repl != nil {
+					newBlock = append(newBlock, repl)
+				}
+			default:
+				newBlock = append(newBlock, stmt)
+			}
+		}
+		newBlocks[bi] = newBlock
+	}
+	return prologue, newBlocks, true
+}
+
+// splitDeclStmt hoists a single `var name [T] [= value]` statement,
+// returning the `var name T` to place in the prologue and (if there was
+// an initializer) the `name = value` to leave behind in place. Anything
+// beyond that single-name shape returns ok=false.
+func splitDeclStmt(s *ast.DeclStmt, info *types.Info, qf types.Qualifier) (prologue, replacement ast.Stmt, ok bool) {
+	gd, ok := s.Decl.(*ast.GenDecl)
+	if !ok || gd.Tok != token.VAR || len(gd.Specs) != 1 {
+		return nil, nil, false
+	}
+	vs, ok := gd.Specs[0].(*ast.ValueSpec)
+	if !ok || len(vs.Names) != 1 {
+		return nil, nil, false
+	}
+	name := vs.Names[0]
+	if name.Name == "_" {
+		return nil, nil, false
+	}
+	obj := info.Defs[name]
+	if obj == nil || !usableType(obj.Type()) {
+		return nil, nil, false
+	}
+	pro := varDecl(name.Name, obj.Type(), qf)
+	if len(vs.Values) == 0 {
+		return pro, nil, true
+	}
+	if len(vs.Values) != 1 {
+		return nil, nil, false
+	}
+	assign := &ast.AssignStmt{Lhs: []ast.Expr{ast.NewIdent(name.Name)}, Tok: token.ASSIGN, Rhs: vs.Values}
+	return pro, assign, true
+}
+
+func varDecl(name string, typ types.Type, qf types.Qualifier) ast.Stmt {
+	return &ast.DeclStmt{Decl: &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{&ast.ValueSpec{
+			Names: []*ast.Ident{ast.NewIdent(name)},
+			Type:  typeExpr(typ, qf),
+		}},
+	}}
+}
+
+func usableType(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	basic, ok := t.(*types.Basic)
+	return !ok || basic.Kind() != types.Invalid
+}
+
+// typeExpr renders typ as source text (using qf to match the target
+// file's own import aliases) and reparses it as an expression, which is
+// far less code than walking types.Type's cases by hand to build the
+// equivalent ast.Expr directly.
+func typeExpr(typ types.Type, qf types.Qualifier) ast.Expr {
+	expr, err := parser.ParseExpr(types.TypeString(typ, qf))
+	if err != nil {
+		return ast.NewIdent("interface{}")
+	}
+	return expr
+}
+
+// opaquePredicateStmt builds a bogus branch around the real state
+// transition: both arms set state to the same next case, so there is
+// no way for this to change behavior, but the condition depends on a
+// runtime value (cfgOpaqueVar), so the compiler can't fold it away and
+// prove the "else" arm dead.
+func opaquePredicateStmt(stateVar *ast.Ident, next int) ast.Stmt {
+	transition := func() ast.Stmt {
+		return &ast.AssignStmt{Lhs: []ast.Expr{stateVar}, Tok: token.ASSIGN, Rhs: []ast.Expr{cfgIntLit(next)}}
+	}
+	return &ast.IfStmt{
+		Cond: opaqueCond(),
+		Body: &ast.BlockStmt{List: []ast.Stmt{transition()}},
+		Else: &ast.BlockStmt{List: []ast.Stmt{transition()}},
+	}
+}
+
+// opaqueCond builds (x*x - x) % 2 == 0 where x is cfgOpaqueVar: x*x and
+// x always share parity, so this is always true, but x isn't a compile
+// time constant, so the "always" can't be proven at compile time.
+func opaqueCond() ast.Expr {
+	x := func() ast.Expr { return ast.NewIdent(cfgOpaqueVar) }
+	mul := &ast.BinaryExpr{X: x(), Op: token.MUL, Y: x()}
+	sub := &ast.BinaryExpr{X: mul, Op: token.SUB, Y: x()}
+	mod := &ast.BinaryExpr{X: sub, Op: token.REM, Y: cfgIntLit(2)}
+	return &ast.BinaryExpr{X: mod, Op: token.EQL, Y: cfgIntLit(0)}
+}
+
+func cfgIntLit(n int) *ast.BasicLit {
+	return &ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(n)}
+}
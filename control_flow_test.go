@@ -0,0 +1,89 @@
+package gobfuscate
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestObfuscateControlFlowSharedOpaqueVar reproduces the reported vet
+// failure: two files in one package both getting opaque predicates
+// each got their own copy of the package-level cfgOpaqueVar appended,
+// redeclaring it. It must be declared once per package.
+func TestObfuscateControlFlowSharedOpaqueVar(t *testing.T) {
+	const aSrc = `package pkg
+
+func A(x int) int {
+	if x > 0 {
+		return x + 1
+	}
+	return x - 1
+}
+`
+	const bSrc = `package pkg
+
+func B(x int) int {
+	if x > 0 {
+		return x + 2
+	}
+	return x - 2
+}
+`
+
+	// ControlFlowHigh injects opaque predicates aggressively; run it
+	// with a handful of seeds (each against a fresh copy of the
+	// package) so the test isn't at the mercy of one seed happening
+	// not to trigger an opaque predicate in either file.
+	for _, seed := range []string{"seed-a", "seed-b", "seed-c"} {
+		gopath := t.TempDir()
+		pkgDir := filepath.Join(gopath, "src", "example.com/pkg")
+		if err := os.MkdirAll(pkgDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(pkgDir, "a.go"), aSrc)
+		writeFile(t, filepath.Join(pkgDir, "b.go"), bSrc)
+
+		if err := ObfuscateControlFlow(gopath, NameHasher(seed), ControlFlowHigh); err != nil {
+			t.Fatalf("seed %q: ObfuscateControlFlow: %v", seed, err)
+		}
+
+		count := countVarDecls(t, filepath.Join(pkgDir, "a.go"), cfgOpaqueVar) +
+			countVarDecls(t, filepath.Join(pkgDir, "b.go"), cfgOpaqueVar)
+		if count > 1 {
+			t.Fatalf("seed %q: %s declared %d times across the package, want at most 1", seed, cfgOpaqueVar, count)
+		}
+
+		buildPackage(t, gopath, "example.com/pkg")
+	}
+}
+
+func countVarDecls(t *testing.T, path, name string) int {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			vs, ok := spec.(*ast.ValueSpec)
+			if !ok {
+				continue
+			}
+			for _, ident := range vs.Names {
+				if ident.Name == name {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,34 @@
+package gobfuscate
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteDebugDir copies every source file under gopath/src into
+// debugDir, preserving each package's (already obfuscated) import path
+// as its subdirectory layout, and, if mapping is non-nil, writes it
+// alongside as debugDir/mapping.json. Call it after all obfuscation
+// passes but before the final go build, so --debugdir captures exactly
+// what got compiled.
+func WriteDebugDir(gopath, debugDir string, mapping *Mapping) error {
+	srcDir := filepath.Join(gopath, "src")
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(debugDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		return copyFile(path, dest)
+	})
+	if err != nil || mapping == nil {
+		return err
+	}
+	return mapping.WriteFile(filepath.Join(debugDir, "mapping.json"))
+}
@@ -0,0 +1,95 @@
+package gobfuscate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// listedPackage mirrors the subset of `go list -json` fields CopyGopath
+// needs to stage a package's sources under the new GOPATH.
+type listedPackage struct {
+	ImportPath   string
+	Dir          string
+	GoFiles      []string
+	OtherFiles   []string
+	Standard     bool
+	TestGoFiles  []string
+	XTestGoFiles []string
+}
+
+// CopyGopath copies pkgName and every non-standard-library package it
+// depends on into a new GOPATH rooted at destGopath, laying each one out
+// at destGopath/src/<importPath>. When keepTests is false, _test.go
+// files are left behind.
+func CopyGopath(pkgName, destGopath string, keepTests bool) error {
+	pkgs, err := listPackages(pkgName)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		if pkg.Standard {
+			continue
+		}
+		if err := copyPackage(pkg, destGopath, keepTests); err != nil {
+			return fmt.Errorf("copy %s: %w", pkg.ImportPath, err)
+		}
+	}
+	return nil
+}
+
+func listPackages(pkgName string) ([]*listedPackage, error) {
+	cmd := exec.Command("go", "list", "-json", "-deps", pkgName)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list %s: %w", pkgName, err)
+	}
+	var pkgs []*listedPackage
+	dec := json.NewDecoder(strings.NewReader(string(out)))
+	for dec.More() {
+		var pkg listedPackage
+		if err := dec.Decode(&pkg); err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, &pkg)
+	}
+	return pkgs, nil
+}
+
+func copyPackage(pkg *listedPackage, destGopath string, keepTests bool) error {
+	destDir := filepath.Join(destGopath, "src", pkg.ImportPath)
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+	files := append([]string{}, pkg.GoFiles...)
+	files = append(files, pkg.OtherFiles...)
+	if keepTests {
+		files = append(files, pkg.TestGoFiles...)
+		files = append(files, pkg.XTestGoFiles...)
+	}
+	for _, name := range files {
+		if err := copyFile(filepath.Join(pkg.Dir, name), filepath.Join(destDir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	return err
+}
@@ -0,0 +1,71 @@
+package gobfuscate
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand"
+)
+
+// NameHasher derives deterministic, seeded identifiers for packages,
+// symbols, and import paths during obfuscation. The underlying bytes
+// act as a salt: the same seed plus the same input always produces the
+// same output, which lets independent passes (package renaming, symbol
+// renaming, cross-package import rewriting) agree on a renamed
+// identifier without talking to each other directly.
+type NameHasher []byte
+
+// Hash returns a short, deterministic, Go-identifier-safe replacement
+// for name, salted with the hasher's seed.
+func (n NameHasher) Hash(name string) string {
+	h := sha256.New()
+	h.Write(n)
+	h.Write([]byte(name))
+	sum := h.Sum(nil)
+	// Prefix with a letter so the result is always a valid identifier:
+	// hex digits alone may start with a digit.
+	return "O" + hex.EncodeToString(sum)[:16]
+}
+
+// NewSeed turns a --seed flag value into a NameHasher. The special
+// value "random" (and the empty string, for callers that haven't
+// opted in yet) draws fresh entropy from crypto/rand; any other value
+// is decoded as base64 and used verbatim, so the same flag value
+// always reproduces the same hasher and therefore the same output
+// across runs.
+func NewSeed(seed string) (NameHasher, error) {
+	if seed == "" || seed == "random" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("generate random seed: %w", err)
+		}
+		return buf, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(seed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --seed %q: expected \"random\" or base64: %w", seed, err)
+	}
+	return decoded, nil
+}
+
+// String base64-encodes the hasher's seed, so the effective seed of a
+// "random" build can be printed and later re-supplied via --seed to
+// reproduce it exactly.
+func (n NameHasher) String() string {
+	return base64.StdEncoding.EncodeToString(n)
+}
+
+// Rand returns a seeded RNG derived from the hasher plus an arbitrary
+// context string (typically a file or package path), so that
+// independent obfuscation decisions draw from independent but still
+// fully deterministic streams for a given seed.
+func (n NameHasher) Rand(context string) *mathrand.Rand {
+	h := sha256.New()
+	h.Write(n)
+	h.Write([]byte(context))
+	seed := int64(binary.BigEndian.Uint64(h.Sum(nil)))
+	return mathrand.New(mathrand.NewSource(seed))
+}
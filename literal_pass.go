@@ -0,0 +1,446 @@
+package gobfuscate
+
+import (
+	"encoding/binary"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"math"
+	mathrand "math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sonnt85/gobfuscate/literals"
+)
+
+// LiteralsMode selects which literal kinds ObfuscateLiterals rewrites.
+type LiteralsMode string
+
+// Values accepted by the --literals flag.
+const (
+	LiteralsAll     LiteralsMode = "all"
+	LiteralsStrings LiteralsMode = "strings"
+	LiteralsNumeric LiteralsMode = "numeric"
+	LiteralsOff     LiteralsMode = "off"
+)
+
+// ObfuscateLiterals walks every non-test Go source file under
+// gopath/src and replaces literal values with calls that reconstruct
+// them at runtime via one of the gobfuscate/literals schemes. Which
+// scheme a given literal gets is chosen per-literal from hasher's
+// seeded RNG, so repeated identical literals don't necessarily decode
+// through identical helpers. LiteralsStrings only touches string
+// literals; LiteralsNumeric touches integer, float, and []byte{...}
+// literals; LiteralsAll does both; LiteralsOff is a no-op.
+//
+// Files are grouped and rewritten one package at a time: the decode
+// helpers a scheme needs are fixed-name package-level funcs, so adding
+// them to every file that happens to use a scheme would redeclare them
+// as soon as a package has more than one such file.
+func ObfuscateLiterals(gopath string, hasher NameHasher, mode LiteralsMode) error {
+	if mode == LiteralsOff {
+		return nil
+	}
+
+	// go/types needs to see this gopath to resolve imports when working
+	// out each literal's target type below - see obfuscateLiteralsInPackage.
+	restoreEnv := setGopathEnv(gopath)
+	defer restoreEnv()
+
+	srcDir := filepath.Join(gopath, "src")
+
+	dirs := map[string][]string{}
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		dir := filepath.Dir(path)
+		dirs[dir] = append(dirs[dir], path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var dirNames []string
+	for dir := range dirs {
+		dirNames = append(dirNames, dir)
+	}
+	sort.Strings(dirNames)
+
+	for _, dir := range dirNames {
+		paths := dirs[dir]
+		sort.Strings(paths)
+		if err := obfuscateLiteralsInPackage(paths, srcDir, hasher, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// obfuscateLiteralsInPackage rewrites literals across every file of a
+// single package together, since the runtime decode helpers a scheme
+// needs (literals.HelperDecls) are fixed-name package-level funcs: two
+// files in the same package both pulling in, say, the XOR helper would
+// redeclare it if each file added its own copy. Helpers are therefore
+// collected across the whole package and spliced into just one file.
+//
+// A literal's replacement must still have the same type the original
+// had in context - assigned to a named string type, returned as a
+// sized int, and so on - or the rewrite just trades a readable build
+// for a broken one. The package is therefore type-checked once up
+// front (with the same typeCheckPackage obfuscateControlFlowInDir uses,
+// but keeping whatever info it recorded past any errors rather than
+// giving up on the package) so each literal can be cast back to
+// its real type; a literal whose type can't be resolved (e.g. it
+// depends on an import go/types couldn't find) is left untouched
+// rather than risk guessing wrong.
+func obfuscateLiteralsInPackage(paths []string, srcDir string, hasher NameHasher, mode LiteralsMode) error {
+	fset := token.NewFileSet()
+	files := make([]*ast.File, len(paths))
+	for i, path := range paths {
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		files[i] = file
+	}
+
+	info, _ := typeCheckPackage(fset, files)
+
+	pkgUsed := map[string]bool{}
+	var changed []int
+	for i, path := range paths {
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		qf := qualifierForFile(files[i])
+		rw := rewriteLiteralsInFile(files[i], filepath.ToSlash(rel), hasher, mode, info, qf)
+		if !rw.changed {
+			continue
+		}
+		changed = append(changed, i)
+		if rw.usedBinary {
+			ensureImport(files[i], "encoding/binary")
+		}
+		if rw.usedMath {
+			ensureImport(files[i], "math")
+		}
+		for kind := range rw.used {
+			pkgUsed[kind] = true
+		}
+		files[i].Decls = append(files[i].Decls, rw.extraDecls...)
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	host := changed[0]
+	files[host].Decls = append(files[host].Decls, literals.HelperDecls(pkgUsed)...)
+
+	for _, i := range changed {
+		out, err := os.Create(paths[i])
+		if err != nil {
+			return err
+		}
+		err = printer.Fprint(out, fset, files[i])
+		out.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func rewriteLiteralsInFile(file *ast.File, hashContext string, hasher NameHasher, mode LiteralsMode, info *types.Info, qf types.Qualifier) *literalRewriter {
+	rw := &literalRewriter{
+		mode: mode,
+		rnd:  hasher.Rand(hashContext),
+		used: map[string]bool{},
+		info: info,
+		qf:   qf,
+	}
+
+	// Record which slots to replace during the walk, but don't mutate
+	// the tree until the walk is done: an obfuscated literal's own
+	// expansion (e.g. a []byte{...} ciphertext literal) would
+	// otherwise look like just another eligible literal, and Inspect
+	// would descend into it and obfuscate its own output forever.
+	var sites []func()
+	queue := func(slot *ast.Expr) {
+		sites = append(sites, func() { *slot = rw.rewrite(*slot) })
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.GenDecl:
+			if node.Tok == token.CONST {
+				// Literal constants must stay compile-time constants;
+				// swapping one for a function call would not build.
+				return false
+			}
+		case *ast.ValueSpec:
+			for i := range node.Values {
+				queue(&node.Values[i])
+			}
+		case *ast.CallExpr:
+			for i := range node.Args {
+				queue(&node.Args[i])
+			}
+		case *ast.CompositeLit:
+			if _, ok := byteSliceLitValue(node); ok {
+				// This whole composite is itself a candidate literal,
+				// queued through whatever slot holds it (ValueSpec,
+				// CallExpr, ...); don't also descend into its elements
+				// as independent int literals.
+				return false
+			}
+			for i := range node.Elts {
+				queue(&node.Elts[i])
+			}
+		case *ast.KeyValueExpr:
+			queue(&node.Value)
+		case *ast.AssignStmt:
+			for i := range node.Rhs {
+				queue(&node.Rhs[i])
+			}
+		case *ast.ReturnStmt:
+			for i := range node.Results {
+				queue(&node.Results[i])
+			}
+		}
+		return true
+	})
+
+	for _, apply := range sites {
+		apply()
+	}
+	return rw
+}
+
+// literalRewriter walks a single file, swapping eligible literals for
+// decode expressions and accumulating everything those expressions
+// need: which helper kinds were used, any extra declarations a
+// stateful scheme (like Split) queued up, and whether the file now
+// needs encoding/binary or math imported.
+type literalRewriter struct {
+	mode       LiteralsMode
+	rnd        *mathrand.Rand
+	used       map[string]bool
+	extraDecls []ast.Decl
+	changed    bool
+	usedBinary bool
+	usedMath   bool
+	info       *types.Info
+	qf         types.Qualifier
+}
+
+func (rw *literalRewriter) rewrite(e ast.Expr) ast.Expr {
+	newExpr, ok := rw.rewriteLiteral(e)
+	if !ok {
+		return e
+	}
+	rw.changed = true
+	return newExpr
+}
+
+func (rw *literalRewriter) rewriteLiteral(e ast.Expr) (ast.Expr, bool) {
+	if lit, ok := e.(*ast.BasicLit); ok {
+		switch lit.Kind {
+		case token.STRING:
+			if rw.mode != LiteralsStrings && rw.mode != LiteralsAll {
+				return nil, false
+			}
+			target, ok := rw.targetType(e)
+			if !ok {
+				return nil, false
+			}
+			value, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				return nil, false
+			}
+			return rw.wrapDecode([]byte(value), conversionWrap(target, rw.qf)), true
+		case token.INT:
+			if rw.mode != LiteralsNumeric && rw.mode != LiteralsAll {
+				return nil, false
+			}
+			target, ok := rw.targetType(e)
+			if !ok {
+				return nil, false
+			}
+			n, err := strconv.ParseInt(lit.Value, 0, 64)
+			if err != nil {
+				return nil, false
+			}
+			var buf [8]byte
+			binary.BigEndian.PutUint64(buf[:], uint64(n))
+			rw.usedBinary = true
+			return rw.wrapDecode(buf[:], intWrap(target, rw.qf)), true
+		case token.FLOAT:
+			if rw.mode != LiteralsNumeric && rw.mode != LiteralsAll {
+				return nil, false
+			}
+			target, ok := rw.targetType(e)
+			if !ok {
+				return nil, false
+			}
+			f, err := strconv.ParseFloat(lit.Value, 64)
+			if err != nil {
+				return nil, false
+			}
+			var buf [8]byte
+			binary.BigEndian.PutUint64(buf[:], math.Float64bits(f))
+			rw.usedBinary = true
+			rw.usedMath = true
+			return rw.wrapDecode(buf[:], floatWrap(target, rw.qf)), true
+		}
+		return nil, false
+	}
+
+	if rw.mode != LiteralsNumeric && rw.mode != LiteralsAll {
+		return nil, false
+	}
+	composite, ok := e.(*ast.CompositeLit)
+	if !ok {
+		return nil, false
+	}
+	data, ok := byteSliceLitValue(composite)
+	if !ok {
+		return nil, false
+	}
+	target, ok := rw.targetType(e)
+	if !ok {
+		return nil, false
+	}
+	return rw.wrapDecode(data, conversionWrap(target, rw.qf)), true
+}
+
+// targetType looks up the type e had in its original context - e.g. a
+// named string type from an explicit var declaration, or a sized int
+// from a function's declared return type - via the package's
+// type-check info. Obfuscation needs that type back, not just
+// whatever the decode expression's own default type would be, or a
+// literal assigned to anything but a plain string/int/float64 (or
+// []byte) would stop compiling. A literal whose type go/types
+// couldn't resolve - typically because it sits behind an import the
+// isolated, single-package type-check here can't see - is reported as
+// not-ok so the caller leaves it untouched instead of guessing.
+func (rw *literalRewriter) targetType(e ast.Expr) (types.Type, bool) {
+	if rw.info == nil {
+		return nil, false
+	}
+	tv, ok := rw.info.Types[e]
+	if !ok || !usableType(tv.Type) {
+		return nil, false
+	}
+	return tv.Type, true
+}
+
+func (rw *literalRewriter) wrapDecode(data []byte, wrap func(ast.Expr) ast.Expr) ast.Expr {
+	obf := literals.Pick(len(data), rw.rnd)
+	rw.used[obf.Kind()] = true
+	expr := obf.Obfuscate(data, rw.rnd)
+	if dp, ok := obf.(literals.DeclProvider); ok {
+		rw.extraDecls = append(rw.extraDecls, dp.Decls()...)
+	}
+	return wrap(expr)
+}
+
+// conversionWrap casts a decode expression to target, so a string or
+// []byte{...} literal comes back as whatever type it actually had in
+// context - a named string type, a named byte-slice type, or plain
+// string/[]byte - instead of always the builtin.
+func conversionWrap(target types.Type, qf types.Qualifier) func(ast.Expr) ast.Expr {
+	typeNode := typeExpr(target, qf)
+	return func(e ast.Expr) ast.Expr {
+		return &ast.CallExpr{Fun: typeNode, Args: []ast.Expr{e}}
+	}
+}
+
+// intWrap and floatWrap reinterpret the decoded 8-byte payload back
+// into the original literal's value, then cast it to target - the
+// type the literal actually had in context (a named numeric type, or
+// a narrower int/float than the decode step naturally produces) -
+// rather than always int/float64.
+func intWrap(target types.Type, qf types.Qualifier) func(ast.Expr) ast.Expr {
+	return func(e ast.Expr) ast.Expr {
+		return &ast.CallExpr{
+			Fun:  typeExpr(target, qf),
+			Args: []ast.Expr{bigEndianUint64Call(e)},
+		}
+	}
+}
+
+func floatWrap(target types.Type, qf types.Qualifier) func(ast.Expr) ast.Expr {
+	return func(e ast.Expr) ast.Expr {
+		return &ast.CallExpr{
+			Fun: typeExpr(target, qf),
+			Args: []ast.Expr{&ast.CallExpr{
+				Fun:  &ast.SelectorExpr{X: ast.NewIdent("math"), Sel: ast.NewIdent("Float64frombits")},
+				Args: []ast.Expr{bigEndianUint64Call(e)},
+			}},
+		}
+	}
+}
+
+func bigEndianUint64Call(e ast.Expr) ast.Expr {
+	return &ast.CallExpr{
+		Fun: &ast.SelectorExpr{
+			X:   &ast.SelectorExpr{X: ast.NewIdent("binary"), Sel: ast.NewIdent("BigEndian")},
+			Sel: ast.NewIdent("Uint64"),
+		},
+		Args: []ast.Expr{e},
+	}
+}
+
+// byteSliceLitValue extracts the constant byte values of a []byte{...}
+// composite literal, so it can be run back through the same literal
+// obfuscators as strings.
+func byteSliceLitValue(composite *ast.CompositeLit) ([]byte, bool) {
+	arr, ok := composite.Type.(*ast.ArrayType)
+	if !ok || arr.Len != nil {
+		return nil, false
+	}
+	elt, ok := arr.Elt.(*ast.Ident)
+	if !ok || elt.Name != "byte" {
+		return nil, false
+	}
+	data := make([]byte, len(composite.Elts))
+	for i, el := range composite.Elts {
+		lit, ok := el.(*ast.BasicLit)
+		if !ok || lit.Kind != token.INT {
+			return nil, false
+		}
+		n, err := strconv.ParseUint(lit.Value, 0, 8)
+		if err != nil {
+			return nil, false
+		}
+		data[i] = byte(n)
+	}
+	return data, true
+}
+
+// ensureImport adds path to file's import block if it isn't already
+// there.
+func ensureImport(file *ast.File, path string) {
+	for _, imp := range file.Imports {
+		if strings.Trim(imp.Path.Value, `"`) == path {
+			return
+		}
+	}
+	spec := &ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: `"` + path + `"`}}
+	file.Imports = append(file.Imports, spec)
+	for _, decl := range file.Decls {
+		if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			gd.Specs = append(gd.Specs, spec)
+			return
+		}
+	}
+	file.Decls = append([]ast.Decl{&ast.GenDecl{Tok: token.IMPORT, Specs: []ast.Spec{spec}}}, file.Decls...)
+}
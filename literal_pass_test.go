@@ -0,0 +1,144 @@
+package gobfuscate
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestObfuscateLiteralsMultiFileHelpers reproduces the reported
+// go vet failure: two files in the same package whose literals drew
+// the same scheme (Shuffle, for both files under this seed) each got
+// their own copy of that scheme's fixed-name helper appended,
+// redeclaring it at package scope. The helper must appear exactly once
+// across the package.
+func TestObfuscateLiteralsMultiFileHelpers(t *testing.T) {
+	gopath := t.TempDir()
+	pkgDir := filepath.Join(gopath, "src", "example.com/pkg")
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(pkgDir, "a.go"), `package pkg
+
+var secretA = "alpha-secret"
+`)
+	writeFile(t, filepath.Join(pkgDir, "b.go"), `package pkg
+
+var secretB = "bravo-secret"
+`)
+
+	if err := ObfuscateLiterals(gopath, NameHasher("test-seed"), LiteralsStrings); err != nil {
+		t.Fatalf("ObfuscateLiterals: %v", err)
+	}
+
+	count := 0
+	for _, name := range []string{"a.go", "b.go"} {
+		count += countHelperDecls(t, filepath.Join(pkgDir, name))
+	}
+	if count != 1 {
+		t.Fatalf("want exactly 1 helper decl across the package, got %d", count)
+	}
+
+	buildPackage(t, gopath, "example.com/pkg")
+}
+
+// TestObfuscateLiteralsCompositeLit covers literals nested inside
+// composite literals (a plain []string{...}), which the original
+// ast.Inspect queue never visited: only ValueSpec/CallExpr/
+// KeyValueExpr/AssignStmt/ReturnStmt slots were queued, so a literal
+// sitting directly in a slice literal's Elts survived untouched.
+func TestObfuscateLiteralsCompositeLit(t *testing.T) {
+	gopath := t.TempDir()
+	pkgDir := filepath.Join(gopath, "src", "example.com/pkg")
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(pkgDir, "a.go"), `package pkg
+
+var secrets = []string{"first-secret", "second-secret"}
+`)
+
+	if err := ObfuscateLiterals(gopath, NameHasher("test-seed"), LiteralsStrings); err != nil {
+		t.Fatalf("ObfuscateLiterals: %v", err)
+	}
+
+	src, err := os.ReadFile(filepath.Join(pkgDir, "a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, plain := range []string{"first-secret", "second-secret"} {
+		if strings.Contains(string(src), plain) {
+			t.Fatalf("literal %q survived obfuscation:\n%s", plain, src)
+		}
+	}
+
+	buildPackage(t, gopath, "example.com/pkg")
+}
+
+// TestObfuscateLiteralsPreservesDeclaredTypes reproduces the reported
+// build failures: a literal assigned to a named string type, returned
+// as a named/sized numeric type, or assigned to a narrower int type
+// must come back as that same type, not the decode step's natural
+// string/int/float64. Building the obfuscated package is the only way
+// to actually catch a wrong cast here.
+func TestObfuscateLiteralsPreservesDeclaredTypes(t *testing.T) {
+	gopath := t.TempDir()
+	pkgDir := filepath.Join(gopath, "src", "example.com/pkg")
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(pkgDir, "a.go"), `package pkg
+
+type Level string
+
+var Debug Level = "debug"
+
+func Tag() Level {
+	return "prod"
+}
+
+var width uint16 = 5
+
+func Area() int64 {
+	return 7
+}
+`)
+
+	if err := ObfuscateLiterals(gopath, NameHasher("test-seed"), LiteralsAll); err != nil {
+		t.Fatalf("ObfuscateLiterals: %v", err)
+	}
+
+	src, err := os.ReadFile(filepath.Join(pkgDir, "a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, plain := range []string{`"debug"`, `"prod"`} {
+		if strings.Contains(string(src), plain) {
+			t.Fatalf("literal %q survived obfuscation:\n%s", plain, src)
+		}
+	}
+
+	buildPackage(t, gopath, "example.com/pkg")
+}
+
+// countHelperDecls counts top-level func decls in path whose name
+// starts with the gobfuscate literal-helper prefix.
+func countHelperDecls(t *testing.T, path string) int {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && strings.HasPrefix(fn.Name.Name, "_gobfuscateLiteral") {
+			n++
+		}
+	}
+	return n
+}
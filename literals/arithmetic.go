@@ -0,0 +1,44 @@
+package literals
+
+import (
+	"go/ast"
+	"math/rand"
+)
+
+// KindArithmetic identifies the Arithmetic scheme for HelperDecls.
+const KindArithmetic = "arithmetic"
+
+// Arithmetic chains each byte's encoding to the one before it
+// (encoded[i] = data[i] + running), so that, unlike XOR, no single
+// byte of ciphertext can be decoded in isolation.
+type Arithmetic struct{}
+
+func (*Arithmetic) Kind() string { return KindArithmetic }
+
+func (*Arithmetic) Obfuscate(data []byte, rnd *rand.Rand) ast.Expr {
+	seed := byte(rnd.Intn(255) + 1)
+	encoded := make([]byte, len(data))
+	running := seed
+	for i, b := range data {
+		encoded[i] = b + running
+		running = b
+	}
+	return &ast.CallExpr{
+		Fun:  ast.NewIdent(arithmeticHelperName),
+		Args: []ast.Expr{byteSliceLit(encoded), intLit(int(seed))},
+	}
+}
+
+const arithmeticHelperName = "_gobfuscateLiteralArithmetic"
+
+var arithmeticHelperSrc = `package p
+func ` + arithmeticHelperName + `(data []byte, seed int) []byte {
+	out := make([]byte, len(data))
+	running := byte(seed)
+	for i, b := range data {
+		out[i] = b - running
+		running = out[i]
+	}
+	return out
+}
+`
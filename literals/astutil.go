@@ -0,0 +1,57 @@
+package literals
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+func byteSliceLit(data []byte) ast.Expr {
+	elems := make([]ast.Expr, len(data))
+	for i, b := range data {
+		elems[i] = intLit(int(b))
+	}
+	return &ast.CompositeLit{
+		Type: &ast.ArrayType{Elt: ast.NewIdent("byte")},
+		Elts: elems,
+	}
+}
+
+func intLit(v int) ast.Expr {
+	return &ast.BasicLit{Kind: token.INT, Value: itoa(v)}
+}
+
+func itoa(v int) string {
+	if v == 0 {
+		return "0"
+	}
+	neg := v < 0
+	if neg {
+		v = -v
+	}
+	var buf [20]byte
+	i := len(buf)
+	for v > 0 {
+		i--
+		buf[i] = byte('0' + v%10)
+		v /= 10
+	}
+	if neg {
+		i--
+		buf[i] = '-'
+	}
+	return string(buf[i:])
+}
+
+// mustParseDecls parses a standalone helper snippet (written as its
+// own tiny "package p") and returns its declarations, ready to be
+// appended to a real file's Decls. It panics on malformed snippets,
+// which only happens if a helper source string above is broken.
+func mustParseDecls(src string) []ast.Decl {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		panic("literals: invalid helper source: " + err.Error())
+	}
+	return f.Decls
+}
@@ -0,0 +1,76 @@
+// Package literals implements pluggable obfuscation schemes for Go
+// literal values (strings, integers, floats, and byte slices). Each
+// scheme turns a literal's raw bytes into an AST expression that
+// reconstructs the original value at runtime, so the literal itself
+// never appears verbatim in the compiled binary.
+package literals
+
+import (
+	"go/ast"
+	"math/rand"
+)
+
+// SizeBudget caps how large a literal's payload can be before Pick
+// falls back to XOR, the cheapest scheme to inline, to keep huge
+// literals (e.g. embedded assets) from blowing up the rewritten AST.
+const SizeBudget = 256
+
+// Obfuscator turns a literal's raw bytes into a runtime-evaluated
+// expression that reconstructs them.
+type Obfuscator interface {
+	Obfuscate(data []byte, rnd *rand.Rand) ast.Expr
+
+	// Kind names the scheme, used to decide which runtime decode
+	// helper(s) a file needs once any of its literals are rewritten.
+	Kind() string
+}
+
+// DeclProvider is implemented by obfuscators (such as Split) that must
+// inject extra package-level declarations - scattered vars, an init
+// func - to support the expression returned from Obfuscate. Callers
+// should type-assert for this after every Obfuscate call and splice
+// the result into the enclosing file.
+type DeclProvider interface {
+	Decls() []ast.Decl
+}
+
+// schemes lists the available obfuscators as factories, so stateful
+// schemes (like Split) get a fresh instance per literal rather than
+// sharing accumulated state across unrelated call sites.
+var schemes = []func() Obfuscator{
+	func() Obfuscator { return &XOR{} },
+	func() Obfuscator { return &Shuffle{} },
+	func() Obfuscator { return &Arithmetic{} },
+	func() Obfuscator { return &Split{} },
+}
+
+// Pick returns a randomly chosen obfuscator for a literal of the given
+// size, drawn from rnd. Literals larger than SizeBudget always get
+// XOR, regardless of what rnd would otherwise have picked.
+func Pick(size int, rnd *rand.Rand) Obfuscator {
+	if size > SizeBudget {
+		return &XOR{}
+	}
+	return schemes[rnd.Intn(len(schemes))]()
+}
+
+// HelperDecls returns the runtime decoder declarations needed to
+// support every obfuscator kind present in used, deduplicated and in a
+// stable order so repeated runs with the same seed produce the same
+// file layout.
+func HelperDecls(used map[string]bool) []ast.Decl {
+	var decls []ast.Decl
+	if used[KindXOR] {
+		decls = append(decls, mustParseDecls(xorHelperSrc)...)
+	}
+	if used[KindShuffle] {
+		decls = append(decls, mustParseDecls(shuffleHelperSrc)...)
+	}
+	if used[KindArithmetic] {
+		decls = append(decls, mustParseDecls(arithmeticHelperSrc)...)
+	}
+	if used[KindSplit] {
+		decls = append(decls, mustParseDecls(splitHelperSrc)...)
+	}
+	return decls
+}
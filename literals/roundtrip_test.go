@@ -0,0 +1,147 @@
+package literals
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestRoundTrip exercises every scheme end to end: obfuscate a payload,
+// splice the resulting expression and its helper decls into a tiny
+// standalone program, compile and run it, and check the program's
+// output against the original bytes. This is the level at which the
+// Split scheme's init-order bug (a package-level var obfuscated with
+// Split read the combined var before init ever populated it) actually
+// showed up - a purely static check of the returned AST would not have
+// caught it.
+func TestRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	schemes := []struct {
+		name string
+		new  func() Obfuscator
+	}{
+		{"xor", func() Obfuscator { return &XOR{} }},
+		{"shuffle", func() Obfuscator { return &Shuffle{} }},
+		{"arithmetic", func() Obfuscator { return &Arithmetic{} }},
+		{"split", func() Obfuscator { return &Split{} }},
+	}
+	payloads := [][]byte{
+		[]byte(""),
+		[]byte("a"),
+		[]byte("TOPSECRETVALUE"),
+		[]byte("the quick brown fox jumps over the lazy dog 0123456789"),
+	}
+
+	for _, scheme := range schemes {
+		for i, payload := range payloads {
+			scheme, payload := scheme, payload
+			t.Run(fmt.Sprintf("%s/%d", scheme.name, i), func(t *testing.T) {
+				obf := scheme.new()
+				rnd := rand.New(rand.NewSource(1))
+				expr := obf.Obfuscate(payload, rnd)
+
+				decls := HelperDecls(map[string]bool{obf.Kind(): true})
+				if dp, ok := obf.(DeclProvider); ok {
+					decls = append(decls, dp.Decls()...)
+				}
+				decls = append(decls, decodeToStdoutFunc(expr))
+
+				got := runProgram(t, renderProgram(decls))
+				if !bytes.Equal(got, payload) {
+					t.Fatalf("round-trip mismatch: got %q, want %q", got, payload)
+				}
+			})
+		}
+	}
+}
+
+// TestRoundTripPackageVar exercises the failure mode reported against
+// Split specifically: a package-level var whose initializer is the
+// obfuscated expression, read from main rather than built up on the
+// fly. Go runs var initializers before any init func, so a scheme that
+// relies on init to populate what it returns would see a zero value.
+func TestRoundTripPackageVar(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	payload := []byte("TOPSECRETVALUE")
+	obf := &Split{}
+	rnd := rand.New(rand.NewSource(2))
+	expr := obf.Obfuscate(payload, rnd)
+
+	decls := HelperDecls(map[string]bool{obf.Kind(): true})
+	decls = append(decls, obf.Decls()...)
+	decls = append(decls, &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{&ast.ValueSpec{
+			Names:  []*ast.Ident{ast.NewIdent("secret")},
+			Values: []ast.Expr{expr},
+		}},
+	})
+	decls = append(decls, decodeToStdoutFunc(ast.NewIdent("secret")))
+
+	got := runProgram(t, renderProgram(decls))
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("package-level var round-trip mismatch: got %q, want %q", got, payload)
+	}
+}
+
+// decodeToStdoutFunc builds "func main() { os.Stdout.Write(expr) }".
+func decodeToStdoutFunc(expr ast.Expr) ast.Decl {
+	return &ast.FuncDecl{
+		Name: ast.NewIdent("main"),
+		Type: &ast.FuncType{Params: &ast.FieldList{}},
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			&ast.ExprStmt{X: &ast.CallExpr{
+				Fun: &ast.SelectorExpr{
+					X:   &ast.SelectorExpr{X: ast.NewIdent("os"), Sel: ast.NewIdent("Stdout")},
+					Sel: ast.NewIdent("Write"),
+				},
+				Args: []ast.Expr{expr},
+			}},
+		}},
+	}
+}
+
+// renderProgram wraps decls (which may reference "os") in a "package
+// main" file and renders it to source.
+func renderProgram(decls []ast.Decl) []byte {
+	imports := &ast.GenDecl{Tok: token.IMPORT, Specs: []ast.Spec{
+		&ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: `"os"`}},
+	}}
+	file := &ast.File{
+		Name:  ast.NewIdent("main"),
+		Decls: append([]ast.Decl{imports}, decls...),
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, token.NewFileSet(), file); err != nil {
+		panic("literals: render program: " + err.Error())
+	}
+	return buf.Bytes()
+}
+
+// runProgram writes src to a scratch file and runs it with `go run`,
+// returning whatever it wrote to stdout.
+func runProgram(t *testing.T, src []byte) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, src, 0644); err != nil {
+		t.Fatalf("write program: %v", err)
+	}
+	out, err := exec.Command("go", "run", path).Output()
+	if err != nil {
+		t.Fatalf("go run failed: %v\nsource:\n%s", err, src)
+	}
+	return out
+}
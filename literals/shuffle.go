@@ -0,0 +1,48 @@
+package literals
+
+import (
+	"go/ast"
+	"math/rand"
+)
+
+// KindShuffle identifies the Shuffle scheme for HelperDecls.
+const KindShuffle = "shuffle"
+
+// Shuffle stores each byte at a randomly permuted position and emits
+// the inverse permutation alongside it, so the runtime helper can walk
+// the permutation to put bytes back in order.
+type Shuffle struct{}
+
+func (*Shuffle) Kind() string { return KindShuffle }
+
+func (*Shuffle) Obfuscate(data []byte, rnd *rand.Rand) ast.Expr {
+	n := len(data)
+	perm := rnd.Perm(n)
+	shuffled := make([]byte, n)
+	for i, p := range perm {
+		shuffled[p] = data[i]
+	}
+	permLit := make([]ast.Expr, n)
+	for i, p := range perm {
+		permLit[i] = intLit(p)
+	}
+	return &ast.CallExpr{
+		Fun: ast.NewIdent(shuffleHelperName),
+		Args: []ast.Expr{
+			byteSliceLit(shuffled),
+			&ast.CompositeLit{Type: &ast.ArrayType{Elt: ast.NewIdent("int")}, Elts: permLit},
+		},
+	}
+}
+
+const shuffleHelperName = "_gobfuscateLiteralShuffle"
+
+var shuffleHelperSrc = `package p
+func ` + shuffleHelperName + `(shuffled []byte, perm []int) []byte {
+	out := make([]byte, len(shuffled))
+	for i, p := range perm {
+		out[i] = shuffled[p]
+	}
+	return out
+}
+`
@@ -0,0 +1,105 @@
+package literals
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"math/rand"
+	"sync/atomic"
+)
+
+// KindSplit identifies the Split scheme for HelperDecls.
+const KindSplit = "split"
+
+// splitCounter keeps generated var names unique within a single run.
+// It does not need to be seeded: only the payload bytes and their
+// arrangement need to be deterministic, and those are drawn from rnd.
+var splitCounter uint64
+
+// Split scatters a literal's bytes across several package-level vars
+// and assembles them back into one slice at the use site, so the
+// ciphertext for a single literal isn't contiguous anywhere in the
+// binary's data section. The chunks hold the literal's bytes as they
+// are: Split scatters them but does not encode them.
+//
+// The join happens through a direct call to splitHelperName at every
+// place the literal is used, not once into a combined var from an
+// init func: Go runs package-level variable initializers before any
+// init func, so a literal obfuscated this way and itself assigned to a
+// package-level var would read the combined var before init ever
+// populated it, silently keeping it zero.
+type Split struct {
+	pending []ast.Decl
+}
+
+func (*Split) Kind() string { return KindSplit }
+
+func (s *Split) Obfuscate(data []byte, rnd *rand.Rand) ast.Expr {
+	id := atomic.AddUint64(&splitCounter, 1)
+
+	chunks := splitChunks(data, rnd)
+	chunkNames := make([]string, len(chunks))
+	var decls []ast.Decl
+	for i, chunk := range chunks {
+		chunkNames[i] = fmt.Sprintf("_gobfuscateSplitChunk%d_%d", id, i)
+		decls = append(decls, &ast.GenDecl{
+			Tok: token.VAR,
+			Specs: []ast.Spec{&ast.ValueSpec{
+				Names:  []*ast.Ident{ast.NewIdent(chunkNames[i])},
+				Values: []ast.Expr{byteSliceLit(chunk)},
+			}},
+		})
+	}
+
+	s.pending = decls
+	return &ast.CallExpr{
+		Fun:  ast.NewIdent(splitHelperName),
+		Args: []ast.Expr{identList(chunkNames)},
+	}
+}
+
+// Decls returns the chunk vars Obfuscate queued up to support the
+// expression it returned.
+func (s *Split) Decls() []ast.Decl {
+	return s.pending
+}
+
+// splitChunks breaks data into 1-4 byte pieces at random boundaries.
+func splitChunks(data []byte, rnd *rand.Rand) [][]byte {
+	var chunks [][]byte
+	for len(data) > 0 {
+		n := 1 + rnd.Intn(4)
+		if n > len(data) {
+			n = len(data)
+		}
+		chunks = append(chunks, data[:n])
+		data = data[n:]
+	}
+	if len(chunks) == 0 {
+		chunks = [][]byte{{}}
+	}
+	return chunks
+}
+
+func identList(names []string) ast.Expr {
+	elems := make([]ast.Expr, len(names))
+	for i, name := range names {
+		elems[i] = ast.NewIdent(name)
+	}
+	return &ast.CompositeLit{
+		Type: &ast.ArrayType{Elt: &ast.ArrayType{Elt: ast.NewIdent("byte")}},
+		Elts: elems,
+	}
+}
+
+const splitHelperName = "_gobfuscateLiteralSplitJoin"
+
+var splitHelperSrc = `package p
+func ` + splitHelperName + `(chunks [][]byte) []byte {
+	var out []byte
+	for _, c := range chunks {
+		out = append(out, c...)
+	}
+	return out
+}
+`
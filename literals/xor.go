@@ -0,0 +1,40 @@
+package literals
+
+import (
+	"go/ast"
+	"math/rand"
+)
+
+// KindXOR identifies the XOR scheme for HelperDecls.
+const KindXOR = "xor"
+
+// XOR decodes each byte of the payload against a single random key
+// byte. It is the simplest scheme, and the fallback for literals too
+// large to be worth a fancier encoding.
+type XOR struct{}
+
+func (*XOR) Kind() string { return KindXOR }
+
+func (*XOR) Obfuscate(data []byte, rnd *rand.Rand) ast.Expr {
+	key := byte(rnd.Intn(255) + 1)
+	encoded := make([]byte, len(data))
+	for i, b := range data {
+		encoded[i] = b ^ key
+	}
+	return &ast.CallExpr{
+		Fun:  ast.NewIdent(xorHelperName),
+		Args: []ast.Expr{byteSliceLit(encoded), intLit(int(key))},
+	}
+}
+
+const xorHelperName = "_gobfuscateLiteralXOR"
+
+var xorHelperSrc = `package p
+func ` + xorHelperName + `(data []byte, key int) []byte {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[i] = b ^ byte(key)
+	}
+	return out
+}
+`
@@ -0,0 +1,103 @@
+package gobfuscate
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Mapping records the original -> hashed name chosen for everything a
+// build renamed, so a --debugdir run can write it out (gated by
+// --emit-mapping) to later symbolicate obfuscated stack traces or
+// package names reported from the field.
+//
+// Methods and struct fields are not renamed by this version of
+// gobfuscate - doing so safely needs whole-program type information, to
+// avoid breaking interface satisfaction and reflection-based code - so
+// those categories stay empty for now. They're included so the mapping
+// file's shape doesn't need to change when that support lands.
+type Mapping struct {
+	Packages map[string]string `json:"packages,omitempty"`
+	Types    map[string]string `json:"types,omitempty"`
+	Funcs    map[string]string `json:"funcs,omitempty"`
+	Methods  map[string]string `json:"methods,omitempty"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	Files    map[string]string `json:"files,omitempty"`
+}
+
+// NewMapping returns an empty Mapping ready to be passed to
+// ObfuscatePackageNames and ObfuscateSymbols.
+func NewMapping() *Mapping {
+	return &Mapping{
+		Packages: map[string]string{},
+		Types:    map[string]string{},
+		Funcs:    map[string]string{},
+		Methods:  map[string]string{},
+		Fields:   map[string]string{},
+		Files:    map[string]string{},
+	}
+}
+
+func (m *Mapping) addPackage(orig, hashed string) {
+	if m == nil {
+		return
+	}
+	m.Packages[orig] = hashed
+}
+
+func (m *Mapping) addType(orig, hashed string) {
+	if m == nil {
+		return
+	}
+	m.Types[orig] = hashed
+}
+
+func (m *Mapping) addFunc(orig, hashed string) {
+	if m == nil {
+		return
+	}
+	m.Funcs[orig] = hashed
+}
+
+func (m *Mapping) addFile(orig, hashed string) {
+	if m == nil {
+		return
+	}
+	m.Files[orig] = hashed
+}
+
+// merge copies every entry from other into m. It's used to replay the
+// renames a cached package produced the last time it was obfuscated,
+// since restoring a cache hit skips rerunning ObfuscateSymbols and so
+// would otherwise leave that package out of the mapping.
+func (m *Mapping) merge(other *Mapping) {
+	if m == nil || other == nil {
+		return
+	}
+	for k, v := range other.Packages {
+		m.Packages[k] = v
+	}
+	for k, v := range other.Types {
+		m.Types[k] = v
+	}
+	for k, v := range other.Funcs {
+		m.Funcs[k] = v
+	}
+	for k, v := range other.Methods {
+		m.Methods[k] = v
+	}
+	for k, v := range other.Fields {
+		m.Fields[k] = v
+	}
+	for k, v := range other.Files {
+		m.Files[k] = v
+	}
+}
+
+// WriteFile writes m as indented JSON to path.
+func (m *Mapping) WriteFile(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,147 @@
+package gobfuscate
+
+import (
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ObfuscatePackageNames walks every package directory under
+// gopath/src, renames each one using hasher, and rewrites every import
+// statement in the tree to point at the new path. Directories are
+// renamed deepest-first so a parent rename never invalidates the path
+// of a child still waiting to be processed.
+func ObfuscatePackageNames(gopath string, hasher NameHasher, mapping *Mapping) error {
+	srcDir := filepath.Join(gopath, "src")
+
+	var dirs []string
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	rename := map[string]string{}
+	for _, dir := range dirs {
+		if dir == srcDir {
+			continue
+		}
+		importPath := filepath.ToSlash(mustRel(srcDir, dir))
+		hashed := hashImportPath(importPath, hasher)
+		rename[importPath] = hashed
+		mapping.addPackage(importPath, hashed)
+	}
+
+	if err := rewriteImports(srcDir, rename); err != nil {
+		return err
+	}
+
+	// Rename the deepest directories first so shallower renames don't
+	// move paths out from under ones still queued.
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], string(filepath.Separator)) > strings.Count(dirs[j], string(filepath.Separator))
+	})
+	for _, dir := range dirs {
+		if dir == srcDir {
+			continue
+		}
+		importPath := filepath.ToSlash(mustRel(srcDir, dir))
+		newPath := filepath.Join(srcDir, filepath.FromSlash(rename[importPath]))
+		if err := os.MkdirAll(newPath, 0755); err != nil {
+			return err
+		}
+		// Move this directory's files rather than the directory itself:
+		// newPath may already exist, holding a child renamed before it.
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			if err := os.Rename(filepath.Join(dir, e.Name()), filepath.Join(newPath, e.Name())); err != nil {
+				return err
+			}
+		}
+		if err := os.Remove(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hashImportPath renames every path component independently so that
+// shared path prefixes (and thus shared parent directories) keep
+// pointing at the same renamed directory.
+func hashImportPath(importPath string, hasher NameHasher) string {
+	comps := strings.Split(importPath, "/")
+	for i, comp := range comps {
+		comps[i] = hasher.Hash(comp)
+	}
+	return strings.Join(comps, "/")
+}
+
+func rewriteImports(srcDir string, rename map[string]string) error {
+	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".go") {
+			return err
+		}
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		changed := false
+		for _, imp := range file.Imports {
+			importPath := strings.Trim(imp.Path.Value, `"`)
+			if newPath, ok := renameImportPath(importPath, rename); ok {
+				imp.Path.Value = `"` + newPath + `"`
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+		out, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		return printer.Fprint(out, fset, file)
+	})
+}
+
+// renameImportPath finds the renamed package directory whose original
+// import path is the longest prefix of importPath, then substitutes it.
+func renameImportPath(importPath string, rename map[string]string) (string, bool) {
+	best := ""
+	for orig := range rename {
+		if (importPath == orig || strings.HasPrefix(importPath, orig+"/")) && len(orig) > len(best) {
+			best = orig
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+	return rename[best] + strings.TrimPrefix(importPath, best), true
+}
+
+func mustRel(base, target string) string {
+	rel, err := filepath.Rel(base, target)
+	if err != nil {
+		panic(err)
+	}
+	return rel
+}
@@ -0,0 +1,91 @@
+package gobfuscate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ObfuscateGopath stages pkgName and every non-standard-library
+// package it depends on into destGopath one package at a time,
+// running ObfuscateLiterals and ObfuscateSymbols on each. cache is
+// consulted before processing a package and populated after, so a
+// later build with the same seed, --literals mode, and unchanged
+// sources can restore the result instead of redoing the AST work.
+// ObfuscatePackageNames and ObfuscateControlFlow still run afterward
+// as separate, whole-tree passes - see Cache's doc comment for why.
+func ObfuscateGopath(pkgName, destGopath string, hasher NameHasher, litMode LiteralsMode, keepTests bool, mapping *Mapping, cache *Cache) error {
+	pkgs, err := listPackages(pkgName)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		if pkg.Standard {
+			continue
+		}
+		if err := obfuscatePackage(pkg, destGopath, hasher, litMode, keepTests, mapping, cache); err != nil {
+			return fmt.Errorf("obfuscate %s: %w", pkg.ImportPath, err)
+		}
+	}
+	return nil
+}
+
+func obfuscatePackage(pkg *listedPackage, destGopath string, hasher NameHasher, litMode LiteralsMode, keepTests bool, mapping *Mapping, cache *Cache) error {
+	destDir := filepath.Join(destGopath, "src", pkg.ImportPath)
+
+	files := append([]string{}, pkg.GoFiles...)
+	files = append(files, pkg.OtherFiles...)
+	if keepTests {
+		files = append(files, pkg.TestGoFiles...)
+		files = append(files, pkg.XTestGoFiles...)
+	}
+	absFiles := make([]string, len(files))
+	for i, name := range files {
+		absFiles[i] = filepath.Join(pkg.Dir, name)
+	}
+
+	key, err := packageKey(absFiles, hasher, passSet(litMode, mapping != nil))
+	if err != nil {
+		return err
+	}
+
+	if entry, ok := cache.lookup(key); ok {
+		if err := copyTree(entry, destDir); err != nil {
+			return err
+		}
+		mapping.merge(cache.mapping(key))
+		return nil
+	}
+
+	// Obfuscate this package alone in a scratch GOPATH: ObfuscateLiterals
+	// and ObfuscateSymbols each walk an entire gopath/src tree, and
+	// running them against destGopath directly would re-touch every
+	// package this loop already finished.
+	stageGopath, err := os.MkdirTemp("", "gobfuscate-pkg-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stageGopath)
+
+	if err := copyPackage(pkg, stageGopath, keepTests); err != nil {
+		return err
+	}
+	if err := ObfuscateLiterals(stageGopath, hasher, litMode); err != nil {
+		return err
+	}
+	local := NewMapping()
+	if err := ObfuscateSymbols(stageGopath, hasher, local); err != nil {
+		return err
+	}
+
+	stageDir := filepath.Join(stageGopath, "src", pkg.ImportPath)
+	if err := copyTree(stageDir, destDir); err != nil {
+		return err
+	}
+	mapping.merge(local)
+
+	if err := cache.store(key, stageDir); err != nil {
+		return err
+	}
+	return cache.storeMapping(key, local)
+}
@@ -0,0 +1,132 @@
+package gobfuscate
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// exemptSymbols are identifiers the Go toolchain or runtime gives
+// special meaning to and which must never be renamed.
+var exemptSymbols = map[string]bool{
+	"main": true,
+	"init": true,
+}
+
+// ObfuscateSymbols walks every package under gopath/src and renames its
+// unexported top-level functions, types, vars, and consts using
+// hasher, rewriting every reference across the package. Exported
+// identifiers are left alone, since they may be part of a dependent
+// package's public API that was already staged into the same GOPATH.
+//
+// Unexported identifiers are scoped to the whole package, not a single
+// file - a helper defined in a.go may well be called from b.go - so a
+// rename decided while looking only at a.go would leave b.go's call
+// site referring to a name that no longer exists. Every file making up
+// a package is therefore parsed and rewritten together.
+func ObfuscateSymbols(gopath string, hasher NameHasher, mapping *Mapping) error {
+	srcDir := filepath.Join(gopath, "src")
+
+	dirs := map[string][]string{}
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".go") {
+			return err
+		}
+		dir := filepath.Dir(path)
+		dirs[dir] = append(dirs[dir], path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var dirNames []string
+	for dir := range dirs {
+		dirNames = append(dirNames, dir)
+	}
+	sort.Strings(dirNames)
+
+	for _, dir := range dirNames {
+		paths := dirs[dir]
+		sort.Strings(paths)
+		importPath := filepath.ToSlash(mustRel(srcDir, dir))
+		if err := obfuscateSymbolsInPackage(paths, importPath, hasher, mapping); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func obfuscateSymbolsInPackage(paths []string, importPath string, hasher NameHasher, mapping *Mapping) error {
+	fset := token.NewFileSet()
+	files := make([]*ast.File, len(paths))
+	for i, path := range paths {
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		files[i] = file
+	}
+
+	rename := map[string]string{}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					addRenameTarget(rename, d.Name, importPath, hasher, mapping.addFunc)
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *ast.TypeSpec:
+						addRenameTarget(rename, s.Name, importPath, hasher, mapping.addType)
+					case *ast.ValueSpec:
+						for _, name := range s.Names {
+							addRenameTarget(rename, name, importPath, hasher, mapping.addFile)
+						}
+					}
+				}
+			}
+		}
+	}
+	if len(rename) == 0 {
+		return nil
+	}
+
+	for i, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok {
+				if newName, ok := rename[ident.Name]; ok {
+					ident.Name = newName
+				}
+			}
+			return true
+		})
+
+		out, err := os.Create(paths[i])
+		if err != nil {
+			return err
+		}
+		err = printer.Fprint(out, fset, file)
+		out.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addRenameTarget(rename map[string]string, name *ast.Ident, importPath string, hasher NameHasher, record func(orig, hashed string)) {
+	if name.IsExported() || exemptSymbols[name.Name] || name.Name == "_" {
+		return
+	}
+	hashed := hasher.Hash(importPath + "#" + name.Name)
+	rename[name.Name] = hashed
+	record(importPath+"."+name.Name, hashed)
+}
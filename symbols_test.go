@@ -0,0 +1,59 @@
+package gobfuscate
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestObfuscateSymbolsCrossFile reproduces the original failure: a
+// helper defined in one file of a package and called from another.
+// Renaming unexported identifiers one file at a time renamed the
+// definition in a.go but left b.go's call site referring to the old
+// name, which no longer built. ObfuscateSymbols must see the whole
+// package at once.
+func TestObfuscateSymbolsCrossFile(t *testing.T) {
+	gopath := t.TempDir()
+	pkgDir := filepath.Join(gopath, "src", "example.com/pkg")
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(pkgDir, "a.go"), `package pkg
+
+func helper() int { return 42 }
+`)
+	writeFile(t, filepath.Join(pkgDir, "b.go"), `package pkg
+
+func Exported() int { return helper() }
+`)
+
+	if err := ObfuscateSymbols(gopath, NameHasher("test-seed"), nil); err != nil {
+		t.Fatalf("ObfuscateSymbols: %v", err)
+	}
+
+	buildPackage(t, gopath, "example.com/pkg")
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// buildPackage compiles importPath out of gopath in GOPATH mode, the
+// most direct way to confirm a rewrite didn't leave a dangling
+// reference behind.
+func buildPackage(t *testing.T, gopath, importPath string) {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	cmd := exec.Command("go", "build", importPath)
+	cmd.Dir = filepath.Join(gopath, "src", importPath)
+	cmd.Env = append(os.Environ(), "GOPATH="+gopath, "GO111MODULE=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build %s: %v\n%s", importPath, err, out)
+	}
+}
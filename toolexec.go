@@ -0,0 +1,44 @@
+package gobfuscate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ToolexecPackage mirrors the subset of `go list -json -deps -export`
+// fields the toolexec wrapper needs to stage and obfuscate a package
+// without disturbing the user's module tree.
+type ToolexecPackage struct {
+	ImportPath string
+	Dir        string
+	Export     string
+	GoFiles    []string
+	Deps       []string
+	Standard   bool
+}
+
+// ListToolexecPackages runs `go list -json -deps -export` against
+// pkgName in the current module and returns every package in its
+// build graph, in the order the toolchain reports them. It is the
+// wrap-mode analogue of CopyGopath's GOPATH walk: instead of copying
+// sources into a scratch GOPATH up front, wrap mode stages and
+// obfuscates packages lazily, one -toolexec invocation at a time.
+func ListToolexecPackages(pkgName string) ([]*ToolexecPackage, error) {
+	cmd := exec.Command("go", "list", "-json", "-deps", "-export", pkgName)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list %s: %w", pkgName, err)
+	}
+	var pkgs []*ToolexecPackage
+	dec := json.NewDecoder(strings.NewReader(string(out)))
+	for dec.More() {
+		var pkg ToolexecPackage
+		if err := dec.Decode(&pkg); err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, &pkg)
+	}
+	return pkgs, nil
+}